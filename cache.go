@@ -0,0 +1,74 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"time"
+)
+
+// CachedResponse is a stored HTTP response, keyed by URL, that lets a
+// Scraper skip or conditionally re-issue a GET.
+type CachedResponse struct {
+	Body         string
+	ETag         string
+	LastModified string
+	StatusCode   int
+	FetchedAt    time.Time
+}
+
+// Cache stores fetched HTTP responses so re-crawling a URL doesn't always
+// hit the network. The default implementation is SQLite-backed (sqliteCache)
+// but any Cache can be plugged into a Scraper, e.g. one backed by
+// httpcache+diskcache.
+type Cache interface {
+	Get(urlStr string) (*CachedResponse, bool, error)
+	Set(urlStr string, resp *CachedResponse) error
+}
+
+// sqliteCache is the default Cache, storing entries in the http_cache table
+// keyed by an FNV hash of the normalized URL.
+type sqliteCache struct {
+	db *sql.DB
+}
+
+// NewSQLiteCache returns a Cache backed by the http_cache table in db.
+func NewSQLiteCache(db *sql.DB) Cache {
+	return &sqliteCache{db: db}
+}
+
+func (c *sqliteCache) Get(urlStr string) (*CachedResponse, bool, error) {
+	row := c.db.QueryRow(`
+		SELECT body, etag, last_modified, status_code, fetched_at
+		FROM http_cache WHERE url_hash = ?`, hashURL(urlStr))
+
+	var resp CachedResponse
+	if err := row.Scan(&resp.Body, &resp.ETag, &resp.LastModified, &resp.StatusCode, &resp.FetchedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &resp, true, nil
+}
+
+func (c *sqliteCache) Set(urlStr string, resp *CachedResponse) error {
+	_, err := c.db.Exec(`
+		INSERT INTO http_cache (url_hash, url, body, etag, last_modified, status_code, fetched_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(url_hash) DO UPDATE SET
+			body = excluded.body,
+			etag = excluded.etag,
+			last_modified = excluded.last_modified,
+			status_code = excluded.status_code,
+			fetched_at = excluded.fetched_at`,
+		hashURL(urlStr), urlStr, resp.Body, resp.ETag, resp.LastModified, resp.StatusCode, resp.FetchedAt)
+	return err
+}
+
+func hashURL(urlStr string) string {
+	h := fnv.New64a()
+	h.Write([]byte(urlStr))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
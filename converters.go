@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/PuerkitoBio/goquery"
+)
+
+// FieldRule extracts one typed field from a page. Selector is a CSS
+// selector; if Attr is set, the named attribute is extracted instead of the
+// element's text.
+type FieldRule struct {
+	Selector string `toml:"selector"`
+	Attr     string `toml:"attr"`
+}
+
+// Converter holds the per-domain CSS selectors used to extract structured
+// fields from a page, in place of the generic <title>/<body> extraction.
+type Converter struct {
+	Domain       string               `toml:"domain"`
+	Title        string               `toml:"title"`
+	Author       string               `toml:"author"`
+	PublishDate  string               `toml:"publish_date"`
+	CanonicalURL string               `toml:"canonical_url"`
+	Content      string               `toml:"content"`
+	Fields       map[string]FieldRule `toml:"fields"`
+}
+
+// ExtractedPage is the result of applying a Converter (or the generic
+// fallback) to a parsed document.
+type ExtractedPage struct {
+	Title        string
+	Body         string
+	Author       string
+	PublishDate  string
+	CanonicalURL string
+	Fields       map[string]string
+}
+
+// Apply runs the converter's selectors against doc.
+func (c *Converter) Apply(doc *goquery.Document) ExtractedPage {
+	var page ExtractedPage
+
+	if c.Title != "" {
+		page.Title = strings.TrimSpace(doc.Find(c.Title).First().Text())
+	}
+	if c.Author != "" {
+		page.Author = strings.TrimSpace(doc.Find(c.Author).First().Text())
+	}
+	if c.PublishDate != "" {
+		page.PublishDate = strings.TrimSpace(doc.Find(c.PublishDate).First().Text())
+	}
+	if c.CanonicalURL != "" {
+		if href, ok := doc.Find(c.CanonicalURL).First().Attr("href"); ok {
+			page.CanonicalURL = href
+		}
+	}
+	if c.Content != "" {
+		page.Body = strings.TrimSpace(doc.Find(c.Content).Text())
+	}
+
+	if len(c.Fields) > 0 {
+		page.Fields = make(map[string]string, len(c.Fields))
+		for name, rule := range c.Fields {
+			sel := doc.Find(rule.Selector).First()
+			if rule.Attr != "" {
+				val, _ := sel.Attr(rule.Attr)
+				page.Fields[name] = val
+			} else {
+				page.Fields[name] = strings.TrimSpace(sel.Text())
+			}
+		}
+	}
+
+	return page
+}
+
+// ConverterSet is a collection of Converters indexed by domain.
+type ConverterSet struct {
+	byDomain map[string]*Converter
+}
+
+// LoadConverters reads every *.toml file in dir into a ConverterSet, keyed
+// by each converter's domain field.
+func LoadConverters(dir string) (*ConverterSet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &ConverterSet{byDomain: make(map[string]*Converter)}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".toml") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		var c Converter
+		if _, err := toml.DecodeFile(path, &c); err != nil {
+			return nil, fmt.Errorf("parsing converter %s: %w", path, err)
+		}
+		if c.Domain == "" {
+			return nil, fmt.Errorf("converter %s is missing a domain", path)
+		}
+
+		cs.byDomain[normalizeDomain(c.Domain)] = &c
+	}
+
+	return cs, nil
+}
+
+// For returns the converter matching host, if any.
+func (cs *ConverterSet) For(host string) (*Converter, bool) {
+	if cs == nil {
+		return nil, false
+	}
+	c, ok := cs.byDomain[normalizeDomain(host)]
+	return c, ok
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(domain), "www.")
+}
+
+// validateConverter is the CLI entry point for checking a converter against
+// a live URL: `serial-scrapist validate-converter -converter site.toml -url https://...`.
+func validateConverter(args []string) error {
+	fs := flag.NewFlagSet("validate-converter", flag.ExitOnError)
+	converterPath := fs.String("converter", "", "path to converter TOML file")
+	targetURL := fs.String("url", "", "URL to validate the converter against")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *converterPath == "" || *targetURL == "" {
+		return fmt.Errorf("usage: validate-converter -converter <path> -url <url>")
+	}
+
+	var c Converter
+	if _, err := toml.DecodeFile(*converterPath, &c); err != nil {
+		return err
+	}
+
+	resp, err := http.Get(*targetURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	page := c.Apply(doc)
+	fmt.Printf("title:         %s\n", page.Title)
+	fmt.Printf("author:        %s\n", page.Author)
+	fmt.Printf("publish_date:  %s\n", page.PublishDate)
+	fmt.Printf("canonical_url: %s\n", page.CanonicalURL)
+	fmt.Printf("content:       %.200s\n", page.Body)
+	for name, value := range page.Fields {
+		fmt.Printf("field[%s]: %s\n", name, value)
+	}
+
+	return nil
+}
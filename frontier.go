@@ -0,0 +1,171 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrFrontierEmpty is returned by GetNextURL when no queued URL is currently
+// eligible to be fetched (either the frontier is drained, or every queued
+// host is still inside its per-host crawl delay).
+var ErrFrontierEmpty = errors.New("no URLs in frontier")
+
+const defaultPerHostDelay = 2 * time.Second
+
+// Frontier is a durable, host-partitioned URL queue backed by SQLite. Unlike
+// a flat file it survives a crash mid-crawl: queued URLs and in-flight
+// "fetching" state both live in the frontier table, so a restart only has to
+// requeue whatever was mid-fetch instead of losing the whole queue.
+type Frontier struct {
+	db           *sql.DB
+	perHostDelay time.Duration
+	mu           sync.Mutex
+
+	hostDelaysMu sync.Mutex
+	hostDelays   map[string]time.Duration
+}
+
+// NewFrontier returns a Frontier backed by db. perHostDelay is the minimum
+// time between two fetches to the same host (Mercator-style politeness).
+func NewFrontier(db *sql.DB, perHostDelay time.Duration) (*Frontier, error) {
+	f := &Frontier{db: db, perHostDelay: perHostDelay, hostDelays: make(map[string]time.Duration)}
+	if err := f.requeueStuck(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// requeueStuck re-queues any URL left in the "fetching" state, which happens
+// if the process died mid-fetch or Run was cancelled after dequeuing a URL
+// but before handing it to a worker. It runs once at startup so crawls are
+// resumable.
+func (f *Frontier) requeueStuck() error {
+	_, err := f.db.Exec(`UPDATE frontier SET status = 'queued' WHERE status = 'fetching'`)
+	return err
+}
+
+// AddURL enqueues urlStr at the given depth if it hasn't been seen before.
+// discoveredFrom records the page the URL was found on, for provenance.
+func (f *Frontier) AddURL(urlStr, discoveredFrom string, depth int) error {
+	host, err := hostOf(urlStr)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, err = f.db.Exec(`
+		INSERT OR IGNORE INTO frontier (url, host, status, priority, retry_count, discovered_from, depth)
+		VALUES (?, ?, 'queued', 0, 0, ?, ?)`,
+		urlStr, host, discoveredFrom, depth)
+	return err
+}
+
+// GetNextURL atomically picks the highest-priority queued URL whose host is
+// past its next_eligible_at, marks it "fetching", and pushes that host's
+// next_eligible_at out by perHostDelay. It returns ErrFrontierEmpty if no
+// queued URL is currently eligible.
+func (f *Frontier) GetNextURL() (string, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tx, err := f.db.Begin()
+	if err != nil {
+		return "", 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var id int64
+	var urlStr, host string
+	var depth int
+	row := tx.QueryRow(`
+		SELECT f.id, f.url, f.host, f.depth
+		FROM frontier f
+		LEFT JOIN host_state h ON h.host = f.host
+		WHERE f.status = 'queued' AND (h.next_eligible_at IS NULL OR h.next_eligible_at <= ?)
+		ORDER BY f.priority DESC, f.id ASC
+		LIMIT 1`, now)
+	if err := row.Scan(&id, &urlStr, &host, &depth); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", 0, ErrFrontierEmpty
+		}
+		return "", 0, err
+	}
+
+	if _, err := tx.Exec(`UPDATE frontier SET status = 'fetching' WHERE id = ?`, id); err != nil {
+		return "", 0, err
+	}
+
+	nextEligible := now.Add(f.delayFor(host))
+	if _, err := tx.Exec(`
+		INSERT INTO host_state (host, next_eligible_at) VALUES (?, ?)
+		ON CONFLICT(host) DO UPDATE SET next_eligible_at = excluded.next_eligible_at`,
+		host, nextEligible); err != nil {
+		return "", 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", 0, err
+	}
+
+	return urlStr, depth, nil
+}
+
+// Pending reports how many URLs are still queued or mid-fetch. Workers use
+// this to tell a truly drained frontier apart from one that's merely
+// waiting out a per-host crawl delay.
+func (f *Frontier) Pending() (int, error) {
+	var n int
+	err := f.db.QueryRow(`SELECT COUNT(*) FROM frontier WHERE status IN ('queued', 'fetching')`).Scan(&n)
+	return n, err
+}
+
+// MarkDone records that url was fetched successfully.
+func (f *Frontier) MarkDone(urlStr string) error {
+	_, err := f.db.Exec(`UPDATE frontier SET status = 'done' WHERE url = ?`, urlStr)
+	return err
+}
+
+// MarkFailed records that fetching url errored, bumping its retry_count.
+func (f *Frontier) MarkFailed(urlStr string) error {
+	_, err := f.db.Exec(`UPDATE frontier SET status = 'failed', retry_count = retry_count + 1 WHERE url = ?`, urlStr)
+	return err
+}
+
+// MarkSkipped records that url was dropped without being fetched, e.g.
+// because robots.txt disallows it.
+func (f *Frontier) MarkSkipped(urlStr string) error {
+	_, err := f.db.Exec(`UPDATE frontier SET status = 'skipped' WHERE url = ?`, urlStr)
+	return err
+}
+
+// SetHostDelay overrides the per-host crawl delay for host, e.g. from a
+// robots.txt Crawl-delay directive.
+func (f *Frontier) SetHostDelay(host string, delay time.Duration) {
+	f.hostDelaysMu.Lock()
+	f.hostDelays[host] = delay
+	f.hostDelaysMu.Unlock()
+}
+
+func (f *Frontier) delayFor(host string) time.Duration {
+	f.hostDelaysMu.Lock()
+	delay, ok := f.hostDelays[host]
+	f.hostDelaysMu.Unlock()
+	if ok {
+		return delay
+	}
+	return f.perHostDelay
+}
+
+func hostOf(urlStr string) (string, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return "", err
+	}
+	return u.Host, nil
+}
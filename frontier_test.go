@@ -0,0 +1,78 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := migrations(db); err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	return db
+}
+
+// TestFrontierRequeuesStuckOnRestart verifies that a URL left in the
+// "fetching" state by a crash is requeued the next time a Frontier is
+// opened against the same db, so a process killed mid-fetch resumes
+// instead of losing that URL for good.
+func TestFrontierRequeuesStuckOnRestart(t *testing.T) {
+	db := openTestDB(t)
+
+	f, err := NewFrontier(db, 0)
+	if err != nil {
+		t.Fatalf("NewFrontier: %v", err)
+	}
+	if err := f.AddURL("https://example.com/a", "", 0); err != nil {
+		t.Fatalf("AddURL: %v", err)
+	}
+
+	urlStr, _, err := f.GetNextURL()
+	if err != nil {
+		t.Fatalf("GetNextURL: %v", err)
+	}
+	if urlStr != "https://example.com/a" {
+		t.Fatalf("got %q, want https://example.com/a", urlStr)
+	}
+
+	var status string
+	if err := db.QueryRow("SELECT status FROM frontier WHERE url = ?", urlStr).Scan(&status); err != nil {
+		t.Fatalf("select status: %v", err)
+	}
+	if status != "fetching" {
+		t.Fatalf("status = %q, want fetching", status)
+	}
+
+	// Simulate a crash: nothing ever calls MarkDone/MarkFailed for urlStr,
+	// then the process restarts and opens a fresh Frontier on the same db.
+	f2, err := NewFrontier(db, 0)
+	if err != nil {
+		t.Fatalf("NewFrontier (restart): %v", err)
+	}
+
+	if err := db.QueryRow("SELECT status FROM frontier WHERE url = ?", urlStr).Scan(&status); err != nil {
+		t.Fatalf("select status after restart: %v", err)
+	}
+	if status != "queued" {
+		t.Fatalf("status after restart = %q, want queued", status)
+	}
+
+	got, _, err := f2.GetNextURL()
+	if err != nil {
+		t.Fatalf("GetNextURL after restart: %v", err)
+	}
+	if got != urlStr {
+		t.Fatalf("got %q, want %q", got, urlStr)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kljensen/snowball/english"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Hit is a single Search result.
+type Hit struct {
+	PageID int64
+	URL    string
+	Title  string
+	Score  float64
+}
+
+// tokenize splits text into lowercase, Unicode-aware word tokens, dropping
+// English stopwords and reducing the rest to their Porter stem so that
+// "crawling" and "crawl" land on the same index term.
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	tokens := make([]string, 0, len(fields))
+	for _, field := range fields {
+		word := strings.ToLower(field)
+		if english.IsStopWord(word) {
+			continue
+		}
+		tokens = append(tokens, english.Stem(word, false))
+	}
+	return tokens
+}
+
+// index replaces pageID's entries in the inverted index with fresh ones
+// derived from body, keeping term_stats.df and doc_stats.length consistent
+// across re-crawls of the same page. All writes happen in a single
+// transaction with prepared statements, rather than one INSERT per term
+// with no transaction.
+func (s *Scraper) index(pageID int64, body string) error {
+	tokens := tokenize(body)
+
+	termFreq := make(map[string]int, len(tokens))
+	for _, term := range tokens {
+		termFreq[term]++
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	oldTerms := make(map[string]bool)
+	rows, err := tx.Query("SELECT DISTINCT term FROM inverted_index WHERE page_id = ?", pageID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			rows.Close()
+			return err
+		}
+		oldTerms[term] = true
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	if _, err := tx.Exec("DELETE FROM inverted_index WHERE page_id = ?", pageID); err != nil {
+		return err
+	}
+
+	insertTerm, err := tx.Prepare("INSERT INTO inverted_index (term, page_id, frequency) VALUES (?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertTerm.Close()
+	for term, freq := range termFreq {
+		if _, err := insertTerm.Exec(term, pageID, freq); err != nil {
+			return err
+		}
+	}
+
+	incDF, err := tx.Prepare(`
+		INSERT INTO term_stats (term, df) VALUES (?, 1)
+		ON CONFLICT(term) DO UPDATE SET df = df + 1`)
+	if err != nil {
+		return err
+	}
+	defer incDF.Close()
+	for term := range termFreq {
+		if !oldTerms[term] {
+			if _, err := incDF.Exec(term); err != nil {
+				return err
+			}
+		}
+	}
+
+	decDF, err := tx.Prepare(`UPDATE term_stats SET df = MAX(df - 1, 0) WHERE term = ?`)
+	if err != nil {
+		return err
+	}
+	defer decDF.Close()
+	for term := range oldTerms {
+		if _, ok := termFreq[term]; !ok {
+			if _, err := decDF.Exec(term); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO doc_stats (page_id, length) VALUES (?, ?)
+		ON CONFLICT(page_id) DO UPDATE SET length = excluded.length`,
+		pageID, len(tokens)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Search ranks pages by Okapi BM25 relevance to query and returns the top k
+// hits. k <= 0 returns every match.
+func (s *Scraper) Search(query string, k int) ([]Hit, error) {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var totalDocs int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM doc_stats").Scan(&totalDocs); err != nil {
+		return nil, err
+	}
+	if totalDocs == 0 {
+		return nil, nil
+	}
+
+	var totalLength int64
+	if err := s.db.QueryRow("SELECT COALESCE(SUM(length), 0) FROM doc_stats").Scan(&totalLength); err != nil {
+		return nil, err
+	}
+	avgdl := float64(totalLength) / float64(totalDocs)
+
+	scores := make(map[int64]float64)
+	docLengths := make(map[int64]int)
+	seen := make(map[string]bool, len(terms))
+
+	for _, term := range terms {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		var df int
+		err := s.db.QueryRow("SELECT df FROM term_stats WHERE term = ?", term).Scan(&df)
+		if errors.Is(err, sql.ErrNoRows) || df == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		idf := math.Log((float64(totalDocs)-float64(df)+0.5)/(float64(df)+0.5) + 1)
+
+		rows, err := s.db.Query("SELECT page_id, frequency FROM inverted_index WHERE term = ?", term)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var pageID int64
+			var freq int
+			if err := rows.Scan(&pageID, &freq); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			length, ok := docLengths[pageID]
+			if !ok {
+				if err := s.db.QueryRow("SELECT length FROM doc_stats WHERE page_id = ?", pageID).Scan(&length); err != nil {
+					rows.Close()
+					return nil, err
+				}
+				docLengths[pageID] = length
+			}
+
+			tf := float64(freq)
+			denom := tf + bm25K1*(1-bm25B+bm25B*float64(length)/avgdl)
+			scores[pageID] += idf * (tf * (bm25K1 + 1) / denom)
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rows.Close()
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for pageID, score := range scores {
+		var urlStr, title string
+		if err := s.db.QueryRow("SELECT url, title FROM pages WHERE id = ?", pageID).Scan(&urlStr, &title); err != nil {
+			continue
+		}
+		hits = append(hits, Hit{PageID: pageID, URL: urlStr, Title: title, Score: score})
+	}
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if k > 0 && len(hits) > k {
+		hits = hits[:k]
+	}
+
+	return hits, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import "testing"
+
+// TestStoreIndexRecrawlIdempotent verifies that re-storing and re-indexing
+// the same URL keeps its pages.id stable and leaves term_stats.df in sync,
+// rather than orphaning the old page_id's rows and double-counting document
+// frequency on every re-crawl.
+func TestStoreIndexRecrawlIdempotent(t *testing.T) {
+	db := openTestDB(t)
+	s := &Scraper{db: db}
+
+	body := "apple banana"
+	pageID1, err := s.store("https://example.com/a", "<html></html>", "A", body)
+	if err != nil {
+		t.Fatalf("store (1st crawl): %v", err)
+	}
+	if err := s.index(pageID1, body); err != nil {
+		t.Fatalf("index (1st crawl): %v", err)
+	}
+
+	pageID2, err := s.store("https://example.com/a", "<html></html>", "A", body)
+	if err != nil {
+		t.Fatalf("store (2nd crawl): %v", err)
+	}
+	if pageID2 != pageID1 {
+		t.Fatalf("pages.id changed across re-crawl: got %d, want %d", pageID2, pageID1)
+	}
+	if err := s.index(pageID2, body); err != nil {
+		t.Fatalf("index (2nd crawl): %v", err)
+	}
+
+	var pageCount int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pages").Scan(&pageCount); err != nil {
+		t.Fatalf("count pages: %v", err)
+	}
+	if pageCount != 1 {
+		t.Fatalf("pages rows = %d, want 1", pageCount)
+	}
+
+	term := tokenize(body)[0]
+	var df int
+	if err := db.QueryRow("SELECT df FROM term_stats WHERE term = ?", term).Scan(&df); err != nil {
+		t.Fatalf("select df: %v", err)
+	}
+	if df != 1 {
+		t.Fatalf("df for %q = %d, want 1 (re-crawl should not double-count)", term, df)
+	}
+
+	var invertedRows int
+	if err := db.QueryRow("SELECT COUNT(*) FROM inverted_index WHERE page_id = ?", pageID1).Scan(&invertedRows); err != nil {
+		t.Fatalf("count inverted_index rows: %v", err)
+	}
+	if invertedRows != 2 {
+		t.Fatalf("inverted_index rows for page = %d, want 2 (one per distinct term)", invertedRows)
+	}
+}
+
+// TestSearchRanksByBM25 builds a small corpus and checks that a short page
+// with a high term frequency for the query outranks a longer page where the
+// term appears only once, as Okapi BM25's length normalization requires.
+func TestSearchRanksByBM25(t *testing.T) {
+	db := openTestDB(t)
+	s := &Scraper{db: db}
+
+	frequent := "crawler crawler crawler crawler"
+	idFrequent, err := s.store("https://example.com/frequent", "", "Frequent", frequent)
+	if err != nil {
+		t.Fatalf("store frequent: %v", err)
+	}
+	if err := s.index(idFrequent, frequent); err != nil {
+		t.Fatalf("index frequent: %v", err)
+	}
+
+	sparse := "crawler web page link site domain host network protocol server"
+	idSparse, err := s.store("https://example.com/sparse", "", "Sparse", sparse)
+	if err != nil {
+		t.Fatalf("store sparse: %v", err)
+	}
+	if err := s.index(idSparse, sparse); err != nil {
+		t.Fatalf("index sparse: %v", err)
+	}
+
+	unrelated := "soup recipe dinner plate"
+	idUnrelated, err := s.store("https://example.com/unrelated", "", "Unrelated", unrelated)
+	if err != nil {
+		t.Fatalf("store unrelated: %v", err)
+	}
+	if err := s.index(idUnrelated, unrelated); err != nil {
+		t.Fatalf("index unrelated: %v", err)
+	}
+
+	hits, err := s.Search("crawler", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("got %d hits, want 2 (unrelated page shouldn't match)", len(hits))
+	}
+	if hits[0].PageID != idFrequent {
+		t.Fatalf("expected the short, high-frequency page to rank first, got page %d first", hits[0].PageID)
+	}
+}
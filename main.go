@@ -1,252 +1,74 @@
 package main
 
 import (
-	"bufio"
 	"container/list"
+	"context"
 	"database/sql"
-	"io"
+	"flag"
 	"log"
-	"net/http"
-	"net/url"
 	"os"
-	"strings"
+	"os/signal"
 	"sync"
-	"time"
+	"syscall"
 
-	"github.com/PuerkitoBio/goquery"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-type Frontier struct {
-	filePath string
-	mu       sync.Mutex
-}
-
-func NewFrontier(filePath string) *Frontier {
-	return &Frontier{filePath: filePath}
-}
-
-func (f *Frontier) AddURL(url string) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-
-	file, err := os.OpenFile(f.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	if _, err := file.WriteString(url + "\n"); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (f *Frontier) GetNextURL() (string, error) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-
-	file, err := os.OpenFile(f.filePath, os.O_RDWR, 0644)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	reader := bufio.NewReader(file)
-	url, err := reader.ReadString('\n')
-	if err != nil {
-		return "", err
-	}
-	url = strings.TrimSpace(url)
-
-	// Read the rest of the file
-	restOfFile, err := io.ReadAll(reader)
-	if err != nil {
-		return "", err
-	}
-
-	// Truncate and rewrite the file without the first line
-	if err := file.Truncate(0); err != nil {
-		return "", err
-	}
-	if _, err := file.Seek(0, 0); err != nil {
-		return "", err
-	}
-	if _, err := file.Write(restOfFile); err != nil {
-		return "", err
-	}
-
-	return url, nil
-}
-
-type Scraper struct {
-	db       *sql.DB
-	visited  *LRUCache
-	frontier *Frontier
-}
-
-func NewScraper(db *sql.DB, frontier *Frontier, cacheSize int) *Scraper {
-	return &Scraper{
-		db:       db,
-		frontier: frontier,
-		visited:  NewLRUCache(cacheSize),
-	}
-}
-
-func (s *Scraper) Run() error {
-	for {
-		url, err := s.frontier.GetNextURL()
-		if err != nil {
-			if err.Error() == "no URLs in frontier" {
-				break
-			}
-			return err
-		}
-
-		err = s.ProcessURL(url)
-		if err != nil {
-			log.Printf("Error processing %s: %v", url, err)
-		}
-	}
-	return nil
-}
-
-func (s *Scraper) ProcessURL(urlStr string) error {
-	// Check if URL has been visited
-	if !s.visited.Add(urlStr) {
-		return nil // URL already visited, skip processing
-	}
-
-	// Fetcher
-	html, err := s.fetch(urlStr)
-	if err != nil {
-		return err
-	}
-
-	// Parser
-	title, body, links, err := s.parse(html)
-	if err != nil {
-		return err
-	}
-
-	// Content store
-	err = s.store(urlStr, html, title, body)
-	if err != nil {
-		return err
-	}
-
-	// URL extractor
-	for _, link := range links {
-		normalizedURL := s.normalizeURL(urlStr, link)
-		if normalizedURL != "" {
-			s.frontier.AddURL(normalizedURL)
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate-converter" {
+		if err := validateConverter(os.Args[2:]); err != nil {
+			log.Fatal(err)
 		}
+		return
 	}
 
-	// Indexer
-	return s.index(urlStr, body)
-}
-
-func (s *Scraper) fetch(urlStr string) (string, error) {
-	resp, err := http.Get(urlStr)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
+	silent := flag.Bool("silent", false, "disable the live progress line")
+	flag.BoolVar(silent, "no-progress", false, "alias for -silent")
+	metricsAddr := flag.String("metrics-addr", "", "if set, serve Prometheus metrics at http://<addr>/metrics")
+	convertersDir := flag.String("converters-dir", "converters", "directory of per-domain *.toml extraction rules")
+	flag.Parse()
 
-	body, err := io.ReadAll(resp.Body)
+	db, err := sql.Open("sqlite3", "db.db")
 	if err != nil {
-		return "", err
+		log.Fatal(err)
 	}
+	defer db.Close()
 
-	return string(body), nil
-}
-
-func (s *Scraper) parse(html string) (string, string, []string, error) {
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	err = migrations(db)
 	if err != nil {
-		return "", "", nil, err
+		log.Fatal(err)
 	}
 
-	title := doc.Find("title").Text()
-	body := doc.Find("body").Text()
-
-	var links []string
-	doc.Find("a").Each(func(i int, s *goquery.Selection) {
-		href, exists := s.Attr("href")
-		if exists {
-			links = append(links, href)
-		}
+	scraper, err := NewScraper(db, 10000, ScraperOptions{ // Cache size of 10,000 URLs
+		Workers:       defaultWorkers,
+		MaxDepth:      defaultMaxDepth,
+		PerHostDelay:  defaultPerHostDelay,
+		CacheTTL:      defaultCacheTTL,
+		UserAgent:     defaultUserAgent,
+		ConvertersDir: *convertersDir,
+		MetricsAddr:   *metricsAddr,
 	})
-
-	return title, body, links, nil
-}
-
-func (s *Scraper) store(urlStr, html, title, body string) error {
-	_, err := s.db.Exec("INSERT OR REPLACE INTO pages (url, html, text, title, last_crawled) VALUES (?, ?, ?, ?, ?)",
-		urlStr, html, body, title, time.Now())
-	return err
-}
-
-func (s *Scraper) normalizeURL(base, href string) string {
-	u, err := url.Parse(href)
 	if err != nil {
-		return ""
-	}
-
-	baseURL, err := url.Parse(base)
-	if err != nil {
-		return ""
-	}
-
-	u = baseURL.ResolveReference(u)
-	normalized := u.String()
-	// Only return the URL if it hasn't been visited
-	if s.visited.Add(normalized) {
-		return normalized
+		log.Fatal(err)
 	}
 
-	return u.String()
-}
+	// Add initial URL
+	scraper.enqueue("https://imdawon.com", "", 0)
 
-func (s *Scraper) index(urlStr, body string) error {
-	words := strings.Fields(body)
-	wordCount := make(map[string]int)
-	for _, word := range words {
-		word = strings.ToLower(word)
-		wordCount[word]++
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	for word, count := range wordCount {
-		_, err := s.db.Exec("INSERT INTO inverted_index (term, page_id, frequency) VALUES (?, (SELECT id FROM pages WHERE url = ?), ?)",
-			word, urlStr, count)
-		if err != nil {
-			return err
-		}
+	var reporter *ProgressReporter
+	if !*silent {
+		reporter = NewProgressReporter(scraper, defaultProgressInterval)
+		reporter.Start()
 	}
 
-	return nil
-}
+	err = scraper.Run(ctx)
 
-func main() {
-	db, err := sql.Open("sqlite3", "db.db")
-	if err != nil {
-		log.Fatal(err)
+	if reporter != nil {
+		reporter.Stop()
 	}
-	defer db.Close()
-
-	err = migrations(db)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	frontier := NewFrontier("frontier.txt")
-	scraper := NewScraper(db, frontier, 10000) // Cache size of 10,000 URLs
-
-	// Add initial URL
-	frontier.AddURL("https://imdawon.com")
-
-	err = scraper.Run()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -267,6 +89,13 @@ func NewLRUCache(capacity int) *LRUCache {
 	}
 }
 
+// Len reports how many keys are currently cached.
+func (c *LRUCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.list.Len()
+}
+
 func (c *LRUCache) Add(key string) bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -297,6 +126,7 @@ func migrations(db *sql.DB) error {
 		html TEXT,
 		text TEXT,
 		title TEXT,
+		status TEXT DEFAULT 'queued',
 		last_crawled TIMESTAMP
 	  );
 
@@ -305,7 +135,7 @@ func migrations(db *sql.DB) error {
 		to_url TEXT,
 		FOREIGN KEY(from_page_id) REFERENCES pages(id)
 	  );
-	  
+
 	  CREATE TABLE IF NOT EXISTS inverted_index (
 		term TEXT,
 		page_id INTEGER,
@@ -313,9 +143,61 @@ func migrations(db *sql.DB) error {
 		FOREIGN KEY(page_id) REFERENCES pages(id)
 	  );
 
+	CREATE TABLE IF NOT EXISTS frontier (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT UNIQUE,
+		host TEXT,
+		status TEXT DEFAULT 'queued',
+		priority INTEGER DEFAULT 0,
+		retry_count INTEGER DEFAULT 0,
+		discovered_from TEXT,
+		depth INTEGER DEFAULT 0
+	  );
+
+	CREATE TABLE IF NOT EXISTS host_state (
+		host TEXT PRIMARY KEY,
+		next_eligible_at TIMESTAMP
+	  );
+
+	CREATE TABLE IF NOT EXISTS http_cache (
+		url_hash TEXT PRIMARY KEY,
+		url TEXT,
+		body TEXT,
+		etag TEXT,
+		last_modified TEXT,
+		status_code INTEGER,
+		fetched_at TIMESTAMP
+	  );
+
+	CREATE TABLE IF NOT EXISTS skipped (
+		url TEXT,
+		reason TEXT,
+		skipped_at TIMESTAMP
+	  );
+
+	CREATE TABLE IF NOT EXISTS page_fields (
+		page_id INTEGER,
+		key TEXT,
+		value TEXT,
+		FOREIGN KEY(page_id) REFERENCES pages(id)
+	  );
+
+	CREATE TABLE IF NOT EXISTS term_stats (
+		term TEXT PRIMARY KEY,
+		df INTEGER DEFAULT 0
+	  );
+
+	CREATE TABLE IF NOT EXISTS doc_stats (
+		page_id INTEGER PRIMARY KEY,
+		length INTEGER,
+		FOREIGN KEY(page_id) REFERENCES pages(id)
+	  );
+
 	CREATE INDEX IF NOT EXISTS idx_links_from_page_id ON links(from_page_id);
 	CREATE INDEX IF NOT EXISTS idx_inverted_index_term ON inverted_index(term);
 	CREATE INDEX IF NOT EXISTS idx_inverted_index_page_id ON inverted_index(page_id);
+	CREATE INDEX IF NOT EXISTS idx_frontier_status_host ON frontier(status, host);
+	CREATE INDEX IF NOT EXISTS idx_page_fields_page_id ON page_fields(page_id);
 	`
 
 	_, err := db.Exec(query)
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultProgressInterval = 2 * time.Second
+
+// labeledCounter is a set of named counters, e.g. fetch_errors_total keyed
+// by reason or http_requests_total keyed by status code.
+type labeledCounter struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+func newLabeledCounter() *labeledCounter {
+	return &labeledCounter{counts: make(map[string]uint64)}
+}
+
+func (c *labeledCounter) Inc(label string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[label]++
+}
+
+func (c *labeledCounter) Snapshot() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]uint64, len(c.counts))
+	for k, v := range c.counts {
+		out[k] = v
+	}
+	return out
+}
+
+func (c *labeledCounter) Total() uint64 {
+	var total uint64
+	for _, v := range c.Snapshot() {
+		total += v
+	}
+	return total
+}
+
+// Metrics holds the atomic counters a Scraper updates as it crawls. They
+// back both the optional progress line and the /metrics endpoint.
+type Metrics struct {
+	PagesFetched uint64 // atomic
+	BytesFetched uint64 // atomic
+	FetchErrors  *labeledCounter
+	HTTPRequests *labeledCounter
+}
+
+// NewMetrics returns a zeroed Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		FetchErrors:  newLabeledCounter(),
+		HTTPRequests: newLabeledCounter(),
+	}
+}
+
+// ServeHTTP renders all counters and the live frontier/visited gauges in
+// Prometheus text exposition format.
+func (s *Scraper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintf(w, "# TYPE pages_fetched_total counter\npages_fetched_total %d\n",
+		atomic.LoadUint64(&s.metrics.PagesFetched))
+
+	fmt.Fprintln(w, "# TYPE fetch_errors_total counter")
+	for reason, count := range s.metrics.FetchErrors.Snapshot() {
+		fmt.Fprintf(w, "fetch_errors_total{reason=%q} %d\n", reason, count)
+	}
+
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	for status, count := range s.metrics.HTTPRequests.Snapshot() {
+		fmt.Fprintf(w, "http_requests_total{status=%q} %d\n", status, count)
+	}
+
+	frontierSize, _ := s.frontier.Pending()
+	fmt.Fprintf(w, "# TYPE frontier_size gauge\nfrontier_size %d\n", frontierSize)
+	fmt.Fprintf(w, "# TYPE visited_cache_size gauge\nvisited_cache_size %d\n", s.visited.Len())
+}
+
+// ProgressReporter prints a live-updating crawl summary to stderr on a
+// ticker: pages/sec, bytes/sec, frontier size, visited count, and error
+// count. It's the Scraper's equivalent of a terminal progress bar, sized for
+// an unbounded, growing frontier rather than a fixed total.
+type ProgressReporter struct {
+	scraper  *Scraper
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewProgressReporter returns a reporter that ticks every interval. interval
+// <= 0 uses defaultProgressInterval.
+func NewProgressReporter(s *Scraper, interval time.Duration) *ProgressReporter {
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	return &ProgressReporter{
+		scraper:  s,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins printing progress in the background.
+func (p *ProgressReporter) Start() {
+	go p.run()
+}
+
+// Stop halts the reporter and prints a final line, mirroring bar.Finish().
+func (p *ProgressReporter) Stop() {
+	close(p.stop)
+	<-p.done
+}
+
+func (p *ProgressReporter) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	var lastPages, lastBytes uint64
+
+	render := func(now time.Time) {
+		pages := atomic.LoadUint64(&p.scraper.metrics.PagesFetched)
+		bytes := atomic.LoadUint64(&p.scraper.metrics.BytesFetched)
+		elapsed := now.Sub(last).Seconds()
+		if elapsed <= 0 {
+			elapsed = p.interval.Seconds()
+		}
+
+		frontierSize, _ := p.scraper.frontier.Pending()
+		fmt.Fprintf(os.Stderr, "\rpages/s=%.1f bytes/s=%.0f frontier=%d visited=%d errors=%d",
+			float64(pages-lastPages)/elapsed,
+			float64(bytes-lastBytes)/elapsed,
+			frontierSize,
+			p.scraper.visited.Len(),
+			p.scraper.metrics.FetchErrors.Total())
+
+		lastPages, lastBytes, last = pages, bytes, now
+	}
+
+	for {
+		select {
+		case <-p.stop:
+			render(time.Now())
+			fmt.Fprintln(os.Stderr)
+			return
+		case now := <-ticker.C:
+			render(now)
+		}
+	}
+}
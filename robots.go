@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+const defaultRobotsTTL = 24 * time.Hour
+
+type robotsEntry struct {
+	group     *robotstxt.Group
+	expiresAt time.Time
+}
+
+// RobotsPolicy enforces robots.txt Allow/Disallow rules and Crawl-delay
+// directives for the configured user agent. Parsed rulesets are cached
+// per-host in memory with a TTL so every URL doesn't trigger a robots.txt
+// fetch.
+type RobotsPolicy struct {
+	fetch     func(string) (string, error)
+	userAgent string
+	ttl       time.Duration
+
+	mu    sync.Mutex
+	rules map[string]*robotsEntry
+}
+
+// NewRobotsPolicy returns a RobotsPolicy that fetches robots.txt via fetch
+// (which should already go through the Scraper's http cache) and evaluates
+// rules for userAgent.
+func NewRobotsPolicy(fetch func(string) (string, error), userAgent string) *RobotsPolicy {
+	return &RobotsPolicy{
+		fetch:     fetch,
+		userAgent: userAgent,
+		ttl:       defaultRobotsTTL,
+		rules:     make(map[string]*robotsEntry),
+	}
+}
+
+// Allowed reports whether urlStr may be fetched under the origin's
+// robots.txt. If robots.txt can't be fetched or parsed, it fails open
+// (allowed) rather than blocking the crawl on a transient error.
+func (p *RobotsPolicy) Allowed(urlStr string) bool {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return true
+	}
+
+	group, err := p.groupFor(u.Scheme + "://" + u.Host)
+	if err != nil || group == nil {
+		return true
+	}
+
+	return group.Test(u.Path)
+}
+
+// CrawlDelay returns the Crawl-delay directive for urlStr's host, or zero if
+// none applies.
+func (p *RobotsPolicy) CrawlDelay(urlStr string) time.Duration {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return 0
+	}
+
+	group, err := p.groupFor(u.Scheme + "://" + u.Host)
+	if err != nil || group == nil {
+		return 0
+	}
+
+	return group.CrawlDelay
+}
+
+func (p *RobotsPolicy) groupFor(origin string) (*robotstxt.Group, error) {
+	p.mu.Lock()
+	entry, ok := p.rules[origin]
+	p.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.group, nil
+	}
+
+	body, err := p.fetch(origin + "/robots.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := robotstxt.FromString(body)
+	if err != nil {
+		return nil, err
+	}
+	group := data.FindGroup(p.userAgent)
+
+	p.mu.Lock()
+	p.rules[origin] = &robotsEntry{group: group, expiresAt: time.Now().Add(p.ttl)}
+	p.mu.Unlock()
+
+	return group, nil
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	defaultWorkers   = 4
+	defaultMaxDepth  = 5
+	defaultCacheTTL  = 24 * time.Hour
+	defaultUserAgent = "serial-scrapist/1.0"
+)
+
+// ScraperOptions configures the concurrency and politeness of a Scraper.
+// Zero values fall back to sane defaults.
+type ScraperOptions struct {
+	// Workers is the number of goroutines fetching pages concurrently.
+	Workers int
+	// MaxDepth caps how many link-hops from the seed URL are followed.
+	// MaxDepth <= 0 means unlimited.
+	MaxDepth int
+	// PerHostDelay is the minimum time between two fetches to the same host.
+	PerHostDelay time.Duration
+	// Cache stores fetched responses so re-runs avoid refetching unchanged
+	// pages. Defaults to a SQLite-backed cache over db.
+	Cache Cache
+	// CacheTTL is how long a cached response is served without revalidation.
+	CacheTTL time.Duration
+	// UserAgent is sent on every request and used to select the matching
+	// robots.txt group.
+	UserAgent string
+	// From, if set, is sent as the From header on every request.
+	From string
+	// ConvertersDir, if set, is a directory of per-domain *.toml extraction
+	// rules loaded at startup. Hosts with no matching converter fall back to
+	// generic <title>/<body> extraction.
+	ConvertersDir string
+	// MetricsAddr, if set, serves Prometheus metrics at http://<addr>/metrics
+	// for the lifetime of Run.
+	MetricsAddr string
+}
+
+type Scraper struct {
+	db          *sql.DB
+	visited     *LRUCache
+	frontier    *Frontier
+	workers     int
+	maxDepth    int
+	cache       Cache
+	cacheTTL    time.Duration
+	userAgent   string
+	from        string
+	robots      *RobotsPolicy
+	converters  *ConverterSet
+	metrics     *Metrics
+	metricsAddr string
+
+	primedHostsMu sync.Mutex
+	primedHosts   map[string]bool
+}
+
+func NewScraper(db *sql.DB, cacheSize int, opts ScraperOptions) (*Scraper, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	perHostDelay := opts.PerHostDelay
+	if perHostDelay <= 0 {
+		perHostDelay = defaultPerHostDelay
+	}
+	cache := opts.Cache
+	if cache == nil {
+		cache = NewSQLiteCache(db)
+	}
+	cacheTTL := opts.CacheTTL
+	if cacheTTL <= 0 {
+		cacheTTL = defaultCacheTTL
+	}
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+
+	var converters *ConverterSet
+	if opts.ConvertersDir != "" {
+		var err error
+		converters, err = LoadConverters(opts.ConvertersDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	frontier, err := NewFrontier(db, perHostDelay)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scraper{
+		db:          db,
+		frontier:    frontier,
+		visited:     NewLRUCache(cacheSize),
+		workers:     workers,
+		maxDepth:    opts.MaxDepth,
+		cache:       cache,
+		cacheTTL:    cacheTTL,
+		userAgent:   userAgent,
+		from:        opts.From,
+		converters:  converters,
+		metrics:     NewMetrics(),
+		metricsAddr: opts.MetricsAddr,
+		primedHosts: make(map[string]bool),
+	}
+	s.robots = NewRobotsPolicy(s.fetch, userAgent)
+
+	return s, nil
+}
+
+// Run drives the crawl with s.workers goroutines pulling URLs off the
+// frontier until it's drained or ctx is cancelled. On cancellation it stops
+// dispatching new work and waits for in-flight fetches to finish before
+// returning, so a SIGINT/SIGTERM mid-crawl leaves the frontier in a
+// resumable state rather than an inconsistent one.
+func (s *Scraper) Run(ctx context.Context) error {
+	type job struct {
+		url   string
+		depth int
+	}
+
+	if s.metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", s.ServeHTTP)
+		server := &http.Server{Addr: s.metricsAddr, Handler: mux}
+
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("metrics server error: %v", err)
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			server.Close()
+		}()
+	}
+
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if !s.robots.Allowed(j.url) {
+					s.recordSkipped(j.url, "disallowed by robots.txt")
+					s.frontier.MarkSkipped(j.url)
+					continue
+				}
+				if delay := s.robots.CrawlDelay(j.url); delay > 0 {
+					if host, err := hostOf(j.url); err == nil {
+						s.frontier.SetHostDelay(host, delay)
+					}
+				}
+
+				if err := s.ProcessURL(j.url, j.depth); err != nil {
+					log.Printf("Error processing %s: %v", j.url, err)
+					s.metrics.FetchErrors.Inc("process")
+					s.frontier.MarkFailed(j.url)
+					continue
+				}
+				atomic.AddUint64(&s.metrics.PagesFetched, 1)
+				s.frontier.MarkDone(j.url)
+			}
+		}()
+	}
+
+dispatch:
+	for {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		default:
+		}
+
+		urlStr, depth, err := s.frontier.GetNextURL()
+		if err != nil {
+			if !errors.Is(err, ErrFrontierEmpty) {
+				close(jobs)
+				wg.Wait()
+				return err
+			}
+
+			pending, err := s.frontier.Pending()
+			if err != nil {
+				close(jobs)
+				wg.Wait()
+				return err
+			}
+			if pending == 0 {
+				break dispatch
+			}
+
+			// Every queued host is still inside its crawl delay; wait it out.
+			select {
+			case <-ctx.Done():
+				break dispatch
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
+		}
+
+		select {
+		case jobs <- job{url: urlStr, depth: depth}:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+	return nil
+}
+
+func (s *Scraper) ProcessURL(urlStr string, depth int) error {
+	// Check if URL has been visited
+	if !s.visited.Add(urlStr) {
+		return nil // URL already visited, skip processing
+	}
+
+	s.markPageStatus(urlStr, "fetching")
+
+	// Fetcher
+	html, err := s.fetch(urlStr)
+	if err != nil {
+		s.markPageStatus(urlStr, "failed")
+		return err
+	}
+
+	// Parser
+	page, links, err := s.parse(urlStr, html)
+	if err != nil {
+		s.markPageStatus(urlStr, "failed")
+		return err
+	}
+
+	// Content store
+	pageID, err := s.store(urlStr, html, page.Title, page.Body)
+	if err != nil {
+		return err
+	}
+
+	// Structured field store
+	if err := s.storeFields(pageID, page); err != nil {
+		return err
+	}
+
+	// URL extractor
+	if s.maxDepth <= 0 || depth < s.maxDepth {
+		for _, link := range links {
+			normalizedURL := s.normalizeURL(urlStr, link)
+			if normalizedURL != "" {
+				s.enqueue(normalizedURL, urlStr, depth+1)
+			}
+		}
+	}
+
+	// Indexer
+	return s.index(pageID, page.Body)
+}
+
+// fetch returns the body for urlStr, serving it from the cache when
+// possible. A fresh cache entry (within cacheTTL) is returned without
+// touching the network. A stale entry is revalidated with a conditional GET
+// (If-None-Match/If-Modified-Since) and only re-fetched on a 200 response.
+func (s *Scraper) fetch(urlStr string) (string, error) {
+	cached, hit, err := s.cache.Get(urlStr)
+	if err != nil {
+		return "", err
+	}
+	if hit && time.Since(cached.FetchedAt) < s.cacheTTL {
+		return cached.Body, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, urlStr, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+	if s.from != "" {
+		req.Header.Set("From", s.from)
+	}
+	if hit {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.metrics.FetchErrors.Inc("network")
+		return "", err
+	}
+	defer resp.Body.Close()
+	s.metrics.HTTPRequests.Inc(strconv.Itoa(resp.StatusCode))
+
+	if resp.StatusCode == http.StatusNotModified && hit {
+		cached.FetchedAt = time.Now()
+		if err := s.cache.Set(urlStr, cached); err != nil {
+			return "", err
+		}
+		return cached.Body, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	atomic.AddUint64(&s.metrics.BytesFetched, uint64(len(body)))
+
+	if resp.StatusCode == http.StatusOK {
+		err := s.cache.Set(urlStr, &CachedResponse{
+			Body:         string(body),
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			StatusCode:   resp.StatusCode,
+			FetchedAt:    time.Now(),
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return string(body), nil
+}
+
+// parse dispatches to the converter matching urlStr's host, if any, falling
+// back to generic <title>/<body> extraction otherwise.
+func (s *Scraper) parse(urlStr, html string) (ExtractedPage, []string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return ExtractedPage{}, nil, err
+	}
+
+	var page ExtractedPage
+	if host, err := hostOf(urlStr); err == nil {
+		if conv, ok := s.converters.For(host); ok {
+			page = conv.Apply(doc)
+		}
+	}
+	if page.Title == "" {
+		page.Title = doc.Find("title").Text()
+	}
+	if page.Body == "" {
+		page.Body = doc.Find("body").Text()
+	}
+
+	var links []string
+	doc.Find("a").Each(func(i int, sel *goquery.Selection) {
+		href, exists := sel.Attr("href")
+		if exists {
+			links = append(links, href)
+		}
+	})
+
+	return page, links, nil
+}
+
+// enqueue records urlStr as a queued page (if it isn't already known) and
+// adds it to the frontier. Using INSERT OR IGNORE here means a page that's
+// already 'done' or 'failed' isn't reset back to 'queued' just because
+// another page links to it again.
+func (s *Scraper) enqueue(urlStr, discoveredFrom string, depth int) error {
+	s.primeHostPolicy(urlStr)
+
+	if _, err := s.db.Exec(`INSERT OR IGNORE INTO pages (url) VALUES (?)`, urlStr); err != nil {
+		return err
+	}
+	return s.frontier.AddURL(urlStr, discoveredFrom, depth)
+}
+
+// primeHostPolicy resolves urlStr's host's robots.txt the first time that
+// host is seen, so its Crawl-delay (if any) is already known before the
+// host's first frontier dequeue. Without this, GetNextURL paces the first
+// request or two to a brand-new host by the frontier's perHostDelay and only learns
+// the real Crawl-delay after a worker fetches the page and calls
+// SetHostDelay, which can be too late to prevent a policy violation.
+func (s *Scraper) primeHostPolicy(urlStr string) {
+	host, err := hostOf(urlStr)
+	if err != nil {
+		return
+	}
+
+	s.primedHostsMu.Lock()
+	if s.primedHosts[host] {
+		s.primedHostsMu.Unlock()
+		return
+	}
+	s.primedHosts[host] = true
+	s.primedHostsMu.Unlock()
+
+	if delay := s.robots.CrawlDelay(urlStr); delay > 0 {
+		s.frontier.SetHostDelay(host, delay)
+	}
+}
+
+// markPageStatus sets pages.status for urlStr, inserting a placeholder row
+// if enqueue hasn't already created one (e.g. a seed URL added before this
+// existed). Errors are logged rather than propagated since a failed status
+// write shouldn't abort an otherwise-successful fetch.
+func (s *Scraper) markPageStatus(urlStr, status string) {
+	_, err := s.db.Exec(`
+		INSERT INTO pages (url, status) VALUES (?, ?)
+		ON CONFLICT(url) DO UPDATE SET status = excluded.status`,
+		urlStr, status)
+	if err != nil {
+		log.Printf("marking %s as %s: %v", urlStr, status, err)
+	}
+}
+
+// store upserts the page by url, keeping its pages.id stable across
+// re-crawls (INSERT OR REPLACE would instead delete and reinsert, handing
+// out a new id every time and orphaning the old id's inverted_index,
+// doc_stats, and page_fields rows).
+func (s *Scraper) store(urlStr, html, title, body string) (int64, error) {
+	_, err := s.db.Exec(`
+		INSERT INTO pages (url, html, text, title, status, last_crawled) VALUES (?, ?, ?, ?, 'done', ?)
+		ON CONFLICT(url) DO UPDATE SET
+			html = excluded.html,
+			text = excluded.text,
+			title = excluded.title,
+			status = excluded.status,
+			last_crawled = excluded.last_crawled`,
+		urlStr, html, body, title, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	var pageID int64
+	if err := s.db.QueryRow("SELECT id FROM pages WHERE url = ?", urlStr).Scan(&pageID); err != nil {
+		return 0, err
+	}
+	return pageID, nil
+}
+
+// storeFields replaces pageID's converter-extracted structured fields
+// (author, publish date, canonical URL, and any custom fields) in
+// page_fields, apart from the body text. index does not read page_fields, so
+// these fields carry no extra weight in Search. It clears the page's
+// existing rows first so re-crawling the same page doesn't accumulate
+// duplicates.
+func (s *Scraper) storeFields(pageID int64, page ExtractedPage) error {
+	fields := map[string]string{}
+	if page.Author != "" {
+		fields["author"] = page.Author
+	}
+	if page.PublishDate != "" {
+		fields["publish_date"] = page.PublishDate
+	}
+	if page.CanonicalURL != "" {
+		fields["canonical_url"] = page.CanonicalURL
+	}
+	for name, value := range page.Fields {
+		fields[name] = value
+	}
+
+	if _, err := s.db.Exec("DELETE FROM page_fields WHERE page_id = ?", pageID); err != nil {
+		return err
+	}
+
+	for key, value := range fields {
+		if _, err := s.db.Exec("INSERT INTO page_fields (page_id, key, value) VALUES (?, ?, ?)", pageID, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scraper) normalizeURL(base, href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+
+	u = baseURL.ResolveReference(u)
+	// Already-seen URLs are not filtered here: the frontier ignores duplicate
+	// inserts and ProcessURL skips anything already in s.visited.
+	return u.String()
+}
+
+func (s *Scraper) recordSkipped(urlStr, reason string) error {
+	_, err := s.db.Exec("INSERT INTO skipped (url, reason, skipped_at) VALUES (?, ?, ?)",
+		urlStr, reason, time.Now())
+	return err
+}
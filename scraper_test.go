@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+// TestNewScraperLoadsConverters verifies that ScraperOptions.ConvertersDir
+// actually loads converters through a real NewScraper call, rather than the
+// feature only being reachable from the validate-converter CLI subcommand.
+func TestNewScraperLoadsConverters(t *testing.T) {
+	db := openTestDB(t)
+
+	s, err := NewScraper(db, 10, ScraperOptions{ConvertersDir: "converters"})
+	if err != nil {
+		t.Fatalf("NewScraper: %v", err)
+	}
+	if s.converters == nil {
+		t.Fatal("converters is nil, want a ConverterSet loaded from ConvertersDir")
+	}
+	if _, ok := s.converters.For("example.com"); !ok {
+		t.Fatal("expected converters/example.com.toml to be loaded")
+	}
+}